@@ -2,6 +2,14 @@
 Package systray is a cross platfrom Go library to place an icon and menu in the notification area.
 Supports Windows, Mac OSX and Linux currently.
 Methods can be called from any goroutine except Run(), which should be called at the very beginning of main() to lock at main thread.
+Run's onExit callback is invoked when the tray is dismissed, whether by Quit() or by the OS, so callers can clean up.
+
+Submenus (AddSubMenuItem), per-item icons (SetIcon/SetTemplateIcon), ResetMenu,
+Hide/Show, and AddMenuItemRadio are implemented here as the cross-platform
+bookkeeping (ids, ordering, locking) that every backend shares; the
+corresponding Win32/Cocoa/GTK rendering in each platform's nativeLoop,
+addOrUpdateMenuItem, resetMenu, hideMenuItem, and showMenuItem implementation
+is tracked and landed separately per platform.
 */
 package systray
 
@@ -30,31 +38,64 @@ type MenuItem struct {
 	remove bool
 	// indicates should be a separator
 	separator bool
+	// clickedCh is sent to when the item is clicked
+	clickedCh chan struct{}
+	// parentID is the id of the menu item this one is nested under, or 0 for a top-level item
+	parentID int32
+	// icon is the PNG-encoded icon shown next to the title, if any
+	icon []byte
+	// isTemplate indicates icon should be treated as a macOS template image,
+	// letting the menu bar invert it automatically for dark/light mode
+	isTemplate bool
+	// hidden menu item is natively hidden rather than removed, preserving its click channel
+	hidden bool
+	// radioGroup is the id of the radio group this item belongs to, or 0 if it's not a radio item
+	radioGroup int
+}
+
+// ClickedCh returns a channel that receives a notification when this menu
+// item is clicked. The channel holds one pending notification and the send is
+// non-blocking, so a slow or absent consumer will not stall the native event
+// loop; clicks that arrive while a notification is still pending are dropped.
+func (item *MenuItem) ClickedCh() <-chan struct{} {
+	return item.clickedCh
 }
 
 var (
 	log = golog.LoggerFor("systray")
 
-	ClickedCh     = make(chan *MenuItem)
 	readyCh       = make(chan interface{})
 	menuItems     = make(map[int32]*MenuItem)
 	menuItemsLock sync.RWMutex
 
+	// topLevelOrder holds the ids of top-level menu items in display order
+	topLevelOrder []int32
+
 	currentID int32
+
+	// onExitFunc is invoked once, on the main thread, after the native loop
+	// returns and before Run returns.
+	onExitFunc func()
 )
 
 // Run initializes GUI and starts the event loop, then invokes the onReady
 // callback.
-// It blocks until systray.Quit() is called.
+// It blocks until systray.Quit() is called, or the tray is dismissed by the
+// OS, at which point onExit is invoked on the main thread before Run returns.
 // Should be called at the very beginning of main() to lock at main thread.
-func Run(onReady func()) {
+func Run(onReady func(), onExit func()) {
 	runtime.LockOSThread()
+	onExitFunc = onExit
 	go func() {
 		<-readyCh
 		onReady()
 	}()
 
 	nativeLoop()
+
+	if onExitFunc != nil {
+		onExitFunc()
+	}
 }
 
 // Quit the systray
@@ -62,17 +103,95 @@ func Quit() {
 	quit()
 }
 
-// AddMenuItem adds menu item with designated title and tooltip, returning a channel
-// that notifies whenever that menu item is clicked.
+// AddMenuItem adds menu item with designated title and tooltip. Use the
+// returned item's ClickedCh() to be notified whenever it is clicked.
 //
 // It can be safely invoked from different goroutines.
 func AddMenuItem(title string, tooltip string, before *MenuItem) *MenuItem {
-	id := atomic.AddInt32(&currentID, 1)
-	item := &MenuItem{id, title, tooltip, false, false, false, false}
+	item := newMenuItem(title, tooltip, 0)
 	item.update(before)
 	return item
 }
 
+// AddMenuItemAt adds menu item with designated title and tooltip, inserted at
+// the given index among the top-level menu items. An out-of-range index
+// appends the item at the end.
+func AddMenuItemAt(index int, title string, tooltip string) *MenuItem {
+	item := newMenuItem(title, tooltip, 0)
+	menuItemsLock.RLock()
+	var before *MenuItem
+	if index >= 0 && index < len(topLevelOrder) {
+		before = menuItems[topLevelOrder[index]]
+	}
+	menuItemsLock.RUnlock()
+	item.update(before)
+	return item
+}
+
+// ResetMenu atomically tears down every menu item and resets the menu to
+// empty, so it can be rebuilt from scratch (e.g. on login/logout or profile
+// switch). It can be safely invoked from different goroutines.
+//
+// currentID is deliberately left untouched: item ids stay monotonic across a
+// reset, so a stale *MenuItem held from before the reset can never alias a
+// genuinely new item created after it.
+func ResetMenu() {
+	menuItemsLock.Lock()
+	defer menuItemsLock.Unlock()
+	menuItems = make(map[int32]*MenuItem)
+	topLevelOrder = nil
+	resetMenu()
+}
+
+// AddSubMenuItem adds a menu item nested under item, with designated title and tooltip.
+// Use the returned item's ClickedCh() to be notified whenever it is clicked.
+//
+// It can be safely invoked from different goroutines.
+func (item *MenuItem) AddSubMenuItem(title string, tooltip string) *MenuItem {
+	sub := newMenuItem(title, tooltip, item.id)
+	sub.update(nil)
+	return sub
+}
+
+// AddSubMenuItemCheckbox adds a checkbox menu item nested under item, with designated
+// title and tooltip and an initial checked state.
+func (item *MenuItem) AddSubMenuItemCheckbox(title string, tooltip string, checked bool) *MenuItem {
+	sub := newMenuItem(title, tooltip, item.id)
+	sub.checked = checked
+	sub.update(nil)
+	return sub
+}
+
+// AddMenuItemRadio adds a menu item with designated title and tooltip to the
+// given radio group. Checking this item (by click or via Check()) automatically
+// unchecks every other item in the same group.
+//
+// groupID 0 is reserved to mean "no radio group" (see MenuItem.radioGroup) and
+// must not be used here; doing so logs an error and produces an item that
+// never participates in mutual exclusion. Pick a non-zero id per group.
+func AddMenuItemRadio(title string, tooltip string, groupID int, before *MenuItem) *MenuItem {
+	if groupID == 0 {
+		log.Error("AddMenuItemRadio called with reserved groupID 0; item will not join a radio group")
+	}
+	item := newMenuItem(title, tooltip, 0)
+	item.radioGroup = groupID
+	item.update(before)
+	return item
+}
+
+// newMenuItem allocates a MenuItem with a freshly assigned id, nested under parentID
+// (0 for a top-level item).
+func newMenuItem(title string, tooltip string, parentID int32) *MenuItem {
+	id := atomic.AddInt32(&currentID, 1)
+	return &MenuItem{
+		id:        id,
+		title:     title,
+		tooltip:   tooltip,
+		clickedCh: make(chan struct{}, 1),
+		parentID:  parentID,
+	}
+}
+
 // SetTitle set the text to display on a menu item
 func (item *MenuItem) SetTitle(title string) {
 	item.title = title
@@ -96,6 +215,29 @@ func (item *MenuItem) SetSeparator(s bool) {
 	item.update(nil)
 }
 
+// SetIcon sets the icon shown next to the menu item's title. iconBytes
+// should be the content of a PNG file.
+func (item *MenuItem) SetIcon(iconBytes []byte) {
+	item.icon = iconBytes
+	item.isTemplate = false
+	item.update(nil)
+}
+
+// SetTemplateIcon sets the icon shown next to the menu item's title, using
+// templateBytes on macOS (as an NSImage template image, so it adapts to the
+// menu bar's dark/light appearance) and regularBytes on Windows and Linux.
+// Both should be the content of a PNG file.
+func (item *MenuItem) SetTemplateIcon(templateBytes []byte, regularBytes []byte) {
+	if runtime.GOOS == "darwin" {
+		item.icon = templateBytes
+		item.isTemplate = true
+	} else {
+		item.icon = regularBytes
+		item.isTemplate = false
+	}
+	item.update(nil)
+}
+
 // Disabled checkes if the menu item is disabled
 func (item *MenuItem) Disabled() bool {
 	return item.disabled
@@ -118,16 +260,26 @@ func (item *MenuItem) Checked() bool {
 	return item.checked
 }
 
-// Check a menu item regardless if it's previously checked or not
+// Check a menu item regardless if it's previously checked or not. If the item
+// belongs to a radio group, every other item in that group is unchecked in
+// the same critical section, so concurrent Check() calls within a group
+// deterministically leave exactly one item checked.
 func (item *MenuItem) Check() {
+	menuItemsLock.Lock()
+	defer menuItemsLock.Unlock()
 	item.checked = true
-	item.update(nil)
+	item.updateLocked(nil)
+	if item.radioGroup != 0 {
+		uncheckRadioSiblingsLocked(item)
+	}
 }
 
 // Uncheck a menu item regardless if it's previously unchecked or not
 func (item *MenuItem) Uncheck() {
+	menuItemsLock.Lock()
+	defer menuItemsLock.Unlock()
 	item.checked = false
-	item.update(nil)
+	item.updateLocked(nil)
 }
 
 // Remove a menu item
@@ -137,24 +289,105 @@ func (item *MenuItem) Remove() {
 	item.update(nil)
 }
 
+// Hide natively hides a menu item, preserving its click channel identity so
+// it can be shown again later without losing subscribers.
+func (item *MenuItem) Hide() {
+	menuItemsLock.Lock()
+	defer menuItemsLock.Unlock()
+	item.hidden = true
+	hideMenuItem(item)
+}
+
+// Show reveals a menu item previously hidden with Hide.
+func (item *MenuItem) Show() {
+	menuItemsLock.Lock()
+	defer menuItemsLock.Unlock()
+	item.hidden = false
+	showMenuItem(item)
+}
+
 // update propogates changes on a menu item to systray
 func (item *MenuItem) update(before *MenuItem) {
 	menuItemsLock.Lock()
 	defer menuItemsLock.Unlock()
+	item.updateLocked(before)
+}
+
+// updateLocked does the work of update. Callers must hold menuItemsLock.
+func (item *MenuItem) updateLocked(before *MenuItem) {
+	if item.remove {
+		if item.parentID == 0 {
+			removeTopLevelOrder(item.id)
+		}
+		delete(menuItems, item.id)
+		addOrUpdateMenuItem(item, before)
+		return
+	}
+	if item.parentID == 0 {
+		if _, existed := menuItems[item.id]; !existed {
+			insertTopLevelOrder(item.id, before)
+		}
+	}
 	menuItems[item.id] = item
 	addOrUpdateMenuItem(item, before)
 }
 
+// uncheckRadioSiblingsLocked unchecks every other item sharing item's radio
+// group. Callers must hold menuItemsLock.
+func uncheckRadioSiblingsLocked(item *MenuItem) {
+	for id, sibling := range menuItems {
+		if id == item.id || sibling.radioGroup != item.radioGroup {
+			continue
+		}
+		sibling.checked = false
+		sibling.updateLocked(nil)
+	}
+}
+
+// insertTopLevelOrder records a newly created top-level item's id in display
+// order, ahead of before if given. Callers must hold menuItemsLock.
+func insertTopLevelOrder(id int32, before *MenuItem) {
+	if before != nil {
+		for i, existingID := range topLevelOrder {
+			if existingID == before.id {
+				topLevelOrder = append(topLevelOrder[:i:i], append([]int32{id}, topLevelOrder[i:]...)...)
+				return
+			}
+		}
+	}
+	topLevelOrder = append(topLevelOrder, id)
+}
+
+// removeTopLevelOrder strips id back out of the display order, e.g. once its
+// item has been removed. Callers must hold menuItemsLock.
+func removeTopLevelOrder(id int32) {
+	for i, existingID := range topLevelOrder {
+		if existingID == id {
+			topLevelOrder = append(topLevelOrder[:i], topLevelOrder[i+1:]...)
+			return
+		}
+	}
+}
+
 func systrayReady() {
 	readyCh <- nil
 }
 
+// systrayMenuItemSelected dispatches a click to the item with the given id,
+// whether it's a top-level item or nested under another via AddSubMenuItem.
+// Clicking a radio item checks it, which unchecks the rest of its group.
 func systrayMenuItemSelected(id int32) {
 	menuItemsLock.RLock()
 	item := menuItems[id]
 	menuItemsLock.RUnlock()
+	if item == nil {
+		return
+	}
+	if item.radioGroup != 0 {
+		item.Check()
+	}
 	select {
-	case ClickedCh <- item:
+	case item.clickedCh <- struct{}{}:
 	// in case no one waiting for the channel
 	default:
 	}
@@ -0,0 +1,89 @@
+package systray
+
+import "testing"
+
+// The real nativeLoop/quit/addOrUpdateMenuItem/resetMenu/hideMenuItem/showMenuItem
+// live in the per-platform backend files (not present in this tree). These
+// no-op stand-ins let the pure Go bookkeeping in systray.go run under `go test`
+// without a native backend.
+func nativeLoop() {}
+func quit()       {}
+func resetMenu()  {}
+
+func addOrUpdateMenuItem(item *MenuItem, before *MenuItem) {}
+func hideMenuItem(item *MenuItem)                          {}
+func showMenuItem(item *MenuItem)                          {}
+
+func TestClickedChFanOut(t *testing.T) {
+	ResetMenu()
+	item1 := AddMenuItem("item1", "", nil)
+	item2 := AddMenuItem("item2", "", nil)
+
+	systrayMenuItemSelected(item1.id)
+
+	select {
+	case <-item1.ClickedCh():
+	default:
+		t.Fatal("expected item1's ClickedCh to receive a notification")
+	}
+	select {
+	case <-item2.ClickedCh():
+		t.Fatal("item2's ClickedCh should not receive item1's click")
+	default:
+	}
+}
+
+func TestAddMenuItemAtInsertsAtIndex(t *testing.T) {
+	ResetMenu()
+	first := AddMenuItem("first", "", nil)
+	third := AddMenuItem("third", "", nil)
+	second := AddMenuItemAt(1, "second", "")
+
+	got := []int32{}
+	for _, id := range topLevelOrder {
+		got = append(got, id)
+	}
+	want := []int32{first.id, second.id, third.id}
+	if len(got) != len(want) {
+		t.Fatalf("topLevelOrder = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("topLevelOrder = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRemovePrunesTopLevelOrderAndMenuItems(t *testing.T) {
+	ResetMenu()
+	item := AddMenuItem("item", "", nil)
+	item.Remove()
+
+	for _, id := range topLevelOrder {
+		if id == item.id {
+			t.Fatalf("topLevelOrder still contains removed item %d", item.id)
+		}
+	}
+	if _, ok := menuItems[item.id]; ok {
+		t.Fatalf("menuItems still contains removed item %d", item.id)
+	}
+}
+
+func TestCheckRadioGroupMutualExclusion(t *testing.T) {
+	ResetMenu()
+	first := AddMenuItemRadio("first", "", 1, nil)
+	second := AddMenuItemRadio("second", "", 1, nil)
+
+	first.Check()
+	if !first.Checked() {
+		t.Fatal("expected first to be checked")
+	}
+
+	second.Check()
+	if second.Checked() != true {
+		t.Fatal("expected second to be checked")
+	}
+	if first.Checked() {
+		t.Fatal("expected first to be unchecked once second was checked")
+	}
+}